@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"greenlight.zhaksylyk.kz/internal/core"
 	"greenlight.zhaksylyk.kz/internal/data"
 	"greenlight.zhaksylyk.kz/internal/validator"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request) {
@@ -23,26 +31,17 @@ func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	// Note that the book variable contains a *pointer* to a Books struct.
-	book := &data.Books{
+
+	book, err := app.core.Books.Create(r.Context(), core.BookInput{
 		Title:   input.Title,
 		Sales:   input.Sales,
 		Pages:   input.Pages,
 		Year:    input.Year,
 		Runtime: input.Runtime,
 		Genres:  input.Genres,
-	}
-	v := validator.New()
-	if data.ValidateBook(v, book); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
-	}
-	// Call the Insert() method on our movies model, passing in a pointer to the
-	// validated book struct. This will create a record in the database and update the
-	// book struct with the system-generated information.
-	err = app.models.Books.Insert(book)
+	})
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.coreErrorResponse(w, r, err)
 		return
 	}
 	// When sending a HTTP response, we want to include a Location header to let the
@@ -53,6 +52,7 @@ func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request
 	headers.Set("Location", fmt.Sprintf("/v1/books/%d", book.ID))
 	// Write a JSON response with a 201 Created status code, the book data in the
 	// response body, and the Location header.
+	setETag(w, book.Version)
 	err = app.writeJSON(w, http.StatusCreated, envelope{"book": book}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -65,19 +65,17 @@ func (app *application) showBookHandler(w http.ResponseWriter, r *http.Request)
 		app.notFoundResponse(w, r)
 		return
 	}
-	// Call the Get() method to fetch the data for a specific book. We also need to
-	// use the errors.Is() function to check if it returns a data.ErrRecordNotFound
-	// error, in which case we send a 404 Not Found response to the client.
-	book, err := app.models.Books.Get(id)
+
+	book, err := app.core.Books.Get(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+		app.coreErrorResponse(w, r, err)
 		return
 	}
+	if checkIfNoneMatch(r, book.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	setETag(w, book.Version)
 	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -91,26 +89,14 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 		app.notFoundResponse(w, r)
 		return
 	}
-	// Fetch the existing book record from the database, sending a 404 Not Found
-	// response to the client if we couldn't find a matching record.
-	book, err := app.models.Books.Get(id)
+
+	ifMatch := r.Header.Get("If-Match")
+	expectedVersion, err := parseETagVersion(ifMatch)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	if r.Header.Get("X-Expected-Version") != "" {
-		if strconv.FormatInt(int64(book.Version), 32) != r.Header.Get("X-Expected-Version") {
-			app.editConflictResponse(w, r)
-			return
-		}
-	}
-
 	// Declare an input struct to hold the expected data from the client.
 	var input struct {
 		Title   *string       `json:"title"`
@@ -126,43 +112,24 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	if input.Title != nil {
-		book.Title = *input.Title
-	}
-	if input.Sales != nil {
-		book.Sales = *input.Sales
-	}
-	if input.Pages != nil {
-		book.Pages = *input.Pages
-	}
-	// We also do the same for the other fields in the input struct.
-	if input.Year != nil {
-		book.Year = *input.Year
-	}
-	if input.Runtime != nil {
-		book.Runtime = *input.Runtime
-	}
-	if input.Genres != nil {
-		book.Genres = input.Genres // Note that we don't need to dereference a slice.
-	}
-	// Validate the updated book record, sending the client a 422 Unprocessable Entity
-	// response if any checks fail.
-	v := validator.New()
-	if data.ValidateBook(v, book); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
-	}
-	// Pass the updated book record to our new Update() method.
-	err = app.models.Books.Update(book)
+
+	book, err := app.core.Books.Update(r.Context(), id, core.BookPatch{
+		Title:   input.Title,
+		Sales:   input.Sales,
+		Pages:   input.Pages,
+		Year:    input.Year,
+		Runtime: input.Runtime,
+		Genres:  input.Genres,
+	}, expectedVersion, ifMatch == "" || ifMatch == "*")
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
+		if errors.Is(err, core.ErrConflict) {
+			app.preconditionFailedResponse(w, r)
+			return
 		}
+		app.coreErrorResponse(w, r, err)
 		return
 	}
+	setETag(w, book.Version)
 	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -177,16 +144,21 @@ func (app *application) deleteBookHandler(w http.ResponseWriter, r *http.Request
 		app.notFoundResponse(w, r)
 		return
 	}
-	// Delete the movie from the database, sending a 404 Not Found response to the
-	// client if there isn't a matching record.
-	err = app.models.Books.Delete(id)
+
+	ifMatch := r.Header.Get("If-Match")
+	expectedVersion, err := parseETagVersion(ifMatch)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.core.Books.Delete(r.Context(), id, expectedVersion, ifMatch == "" || ifMatch == "*")
+	if err != nil {
+		if errors.Is(err, core.ErrConflict) {
+			app.preconditionFailedResponse(w, r)
+			return
 		}
+		app.coreErrorResponse(w, r, err)
 		return
 	}
 	// Return a 200 OK status code along with a success message.
@@ -196,6 +168,253 @@ func (app *application) deleteBookHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// importRow is one line of a bulk-import body after parsing: either a book
+// (parse succeeded) or an err (parse failed), tagged with the line it came
+// from so a bad row doesn't disturb the line numbering of the rows around
+// it.
+type importRow struct {
+	line int
+	book *data.Books
+	err  error
+}
+
+// bulkImportBooksHandler accepts a CSV or NDJSON body (chosen by Content-Type)
+// of books to create, validates each row independently, and bulk-inserts the
+// valid ones in a single transaction. The response reports a status per input
+// line so the caller can see exactly which rows succeeded; a malformed row
+// doesn't stop the rows around it from being imported and reported on.
+func (app *application) bulkImportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+
+	var rows []importRow
+	var err error
+
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		rows, err = readBooksCSV(r.Body)
+	case strings.HasPrefix(contentType, "application/x-ndjson"):
+		rows, err = readBooksNDJSON(r.Body)
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("unsupported Content-Type %q, expected text/csv or application/x-ndjson", contentType))
+		return
+	}
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	results := make([]data.ImportResult, len(rows))
+	var toInsert []data.ImportRow
+	for i, row := range rows {
+		if row.err != nil {
+			results[i] = data.ImportResult{Line: row.line, Status: "invalid", Error: row.err.Error()}
+			continue
+		}
+		toInsert = append(toInsert, data.ImportRow{Line: row.line, Book: row.book})
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	inserted, err := app.core.Books.Import(ctx, toInsert)
+	if err != nil && inserted == nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// toInsert (and so inserted) was built by walking rows in order and
+	// skipping the ones that failed to parse, so replaying that same walk
+	// lines the insert/validation results back up with the parse-error
+	// results already sitting in their slots.
+	j := 0
+	for i, row := range rows {
+		if row.err == nil {
+			results[i] = inserted[j]
+			j++
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readBooksCSV parses a "title,sales,pages,year,runtime,genres" CSV body,
+// with genres as a "|"-separated list within the field. The header (line 1)
+// must match exactly, but a malformed data row doesn't abort the read: it's
+// carried forward as a row with err set so the rows around it still get
+// imported.
+func readBooksCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	wantHeader := []string{"title", "sales", "pages", "year", "runtime", "genres"}
+	if len(header) != len(wantHeader) {
+		return nil, fmt.Errorf("CSV header must be: %s", strings.Join(wantHeader, ","))
+	}
+	for i := range wantHeader {
+		if header[i] != wantHeader[i] {
+			return nil, fmt.Errorf("CSV header must be: %s", strings.Join(wantHeader, ","))
+		}
+	}
+
+	var rows []importRow
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			rows = append(rows, importRow{line: line, err: fmt.Errorf("invalid CSV row: %w", err)})
+			continue
+		}
+		book, err := parseBookCSVRecord(record)
+		if err != nil {
+			rows = append(rows, importRow{line: line, err: err})
+			continue
+		}
+		rows = append(rows, importRow{line: line, book: book})
+	}
+	return rows, nil
+}
+
+func parseBookCSVRecord(record []string) (*data.Books, error) {
+	if len(record) != 6 {
+		return nil, fmt.Errorf("expected 6 fields, got %d", len(record))
+	}
+
+	sales, err := strconv.ParseInt(record[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sales value %q", record[1])
+	}
+	pages, err := strconv.ParseInt(record[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pages value %q", record[2])
+	}
+	year, err := strconv.ParseInt(record[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid year value %q", record[3])
+	}
+	runtime, err := strconv.ParseInt(record[4], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid runtime value %q", record[4])
+	}
+
+	return &data.Books{
+		Title:   record[0],
+		Sales:   int32(sales),
+		Pages:   int32(pages),
+		Year:    int32(year),
+		Runtime: data.Runtime(runtime),
+		Genres:  strings.Split(record[5], "|"),
+	}, nil
+}
+
+// readBooksNDJSON parses a newline-delimited JSON body, one book object per
+// line, using the same field names as the create/update handlers. Lines are
+// decoded independently (rather than streamed through one json.Decoder) so
+// that a malformed line doesn't leave the decoder unable to parse the rows
+// after it; blank lines are skipped without consuming a result slot.
+func readBooksNDJSON(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []importRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var book data.Books
+		if err := json.Unmarshal([]byte(text), &book); err != nil {
+			rows = append(rows, importRow{line: line, err: fmt.Errorf("invalid NDJSON on line %d: %w", line, err)})
+			continue
+		}
+		rows = append(rows, importRow{line: line, book: &book})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// bulkExportBooksHandler streams every book matching the same title/genres/
+// sort filters as listBooksHandler, as CSV or NDJSON depending on Accept.
+// Rows are written as they come off a server-side cursor so the handler's
+// memory use doesn't grow with the result size.
+func (app *application) bulkExportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title  string
+		Genres []string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+	input.Title = app.readString(qs, "title", "")
+	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "title", "sales", "pages", "year", "runtime", "-id", "-sales", "-pages", "-title", "-year", "-runtime"}
+
+	// Validated here too, not just inside core.Books.Export: this response
+	// is streamed, and once the CSV/NDJSON header below is written the
+	// response is committed, too late to turn a bad filter into a proper
+	// 422 instead of a truncated 200. Export has no Page/PageSize (it
+	// streams every matching row off a cursor), so it checks Sort against
+	// the safelist directly instead of ValidateFilters, which would reject
+	// the zero-valued Page/PageSize this handler never sets.
+	v := validator.New()
+	if data.ValidateSort(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	accept := r.Header.Get("Accept")
+
+	if strings.Contains(accept, "application/x-ndjson") {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		err := app.core.Books.Export(ctx, input.Title, input.Genres, input.Filters, func(book *data.Books) error {
+			return enc.Encode(book)
+		})
+		if err != nil {
+			// Headers and part of the body may already be written, so we can
+			// only log the failure rather than send an error response.
+			app.logger.PrintError(err, nil)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "title", "sales", "pages", "year", "runtime", "genres"})
+	err := app.core.Books.Export(ctx, input.Title, input.Genres, input.Filters, func(book *data.Books) error {
+		return cw.Write([]string{
+			strconv.FormatInt(book.ID, 10),
+			book.Title,
+			strconv.FormatInt(int64(book.Sales), 10),
+			strconv.FormatInt(int64(book.Pages), 10),
+			strconv.FormatInt(int64(book.Year), 10),
+			strconv.FormatInt(int64(book.Runtime), 10),
+			strings.Join(book.Genres, "|"),
+		})
+	})
+	cw.Flush()
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
+
 func (app *application) listBooksHandler(w http.ResponseWriter, r *http.Request) {
 	// Embed the new Filters struct.
 	var input struct {
@@ -203,30 +422,52 @@ func (app *application) listBooksHandler(w http.ResponseWriter, r *http.Request)
 		Sales  int32
 		Pages  int32
 		Genres []string
+		Q      string
+		Lang   string
 		data.Filters
 	}
 	v := validator.New()
 	qs := r.URL.Query()
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	// q is the websearch_to_tsquery search string; when set, it takes over
+	// from the plain title filter and lang picks its text search config.
+	input.Q = app.readString(qs, "q", "")
+	input.Lang = app.readString(qs, "lang", "simple")
 	// Read the page and page_size query string values into the embedded struct.
 	input.Filters.Sales = app.readInt(qs, "sales", 0, v)
+	input.Filters.Pages = app.readInt(qs, "pages", 0, v)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-	// Read the sort query string value into the embedded struct.
-	input.Filters.Sort = app.readString(qs, "sort", "id")
+	// Read the sort query string value into the embedded struct. A search
+	// query defaults to relevance order rather than "id".
+	defaultSort := "id"
+	if input.Q != "" {
+		defaultSort = "relevance"
+	}
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
 
-	input.Filters.SortSafelist = []string{"id", "title", "sales", "pages", "year", "runtime", "-id", "-sales", "-pages", "-title", "-year", "-runtime"}
-	// Execute the validation checks on the Filters struct and send a response
-	// containing the errors if necessary.
-	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+	// Execute the validation checks on the query string values collected so far
+	// and send a response containing the errors if necessary. The sort
+	// safelist and pagination policy now live in core.Books.List.
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Books.GetAll(input.Title, input.Sales, input.Pages, input.Genres, input.Filters)
+	movies, metadata, err := app.core.Books.List(r.Context(), core.BookListQuery{
+		Title:    input.Title,
+		Sales:    input.Filters.Sales,
+		Pages:    input.Filters.Pages,
+		Genres:   input.Genres,
+		Q:        input.Q,
+		Lang:     input.Lang,
+		Sort:     input.Filters.Sort,
+		Page:     input.Filters.Page,
+		PageSize: input.Filters.PageSize,
+	})
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.coreErrorResponse(w, r, err)
 		return
 	}
 
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"greenlight.zhaksylyk.kz/internal/core"
+)
+
+// etagValue formats a record's version as a strong ETag, e.g. `"5"`.
+func etagValue(version int32) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setETag sets the response's ETag header from the given version. The book
+// handlers that return a single book (create, show, update) call this so
+// that clients can make conditional requests against it.
+func setETag(w http.ResponseWriter, version int32) {
+	w.Header().Set("ETag", etagValue(version))
+}
+
+// checkIfNoneMatch reports whether the request's If-None-Match header
+// already matches the current version, in which case the caller should
+// respond 304 Not Modified instead of resending the representation.
+func checkIfNoneMatch(r *http.Request, version int32) bool {
+	match := r.Header.Get("If-None-Match")
+	if match == "" {
+		return false
+	}
+	if match == "*" {
+		return true
+	}
+	return match == etagValue(version)
+}
+
+// preconditionFailedResponse sends a 412 Precondition Failed response, used
+// when a write's If-Match header doesn't match the record's current ETag.
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// parseETagVersion extracts the integer version carried by an If-Match (or
+// If-None-Match) header value such as `"5"`. An empty or wildcard "*" value
+// has no version to parse and returns 0, which callers must pair with their
+// own "matches anything" handling rather than treating as a real version.
+func parseETagVersion(etag string) (int32, error) {
+	if etag == "" || etag == "*" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(strings.Trim(etag, `"`), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match value %q", etag)
+	}
+	return int32(v), nil
+}
+
+// coreErrorResponse translates an error returned by an internal/core service
+// method into the appropriate HTTP error response.
+func (app *application) coreErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *core.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		app.failedValidationResponse(w, r, verr.Errors)
+	case errors.Is(err, core.ErrNotFound):
+		app.notFoundResponse(w, r)
+	case errors.Is(err, core.ErrConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
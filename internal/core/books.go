@@ -0,0 +1,223 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"greenlight.zhaksylyk.kz/internal/data"
+	"greenlight.zhaksylyk.kz/internal/validator"
+)
+
+// bookSortSafelist mirrors the columns listBooksHandler used to hard-code
+// itself; it now lives here so every caller of Books.List shares the same
+// policy instead of each HTTP handler having to restate it.
+var bookSortSafelist = []string{"id", "title", "sales", "pages", "year", "runtime", "relevance", "-id", "-sales", "-pages", "-title", "-year", "-runtime", "-relevance"}
+
+// BookInput carries user-supplied book fields for creation, independent of
+// how the request was transported (HTTP JSON body, CSV row, CLI flags, ...).
+type BookInput struct {
+	Title   string
+	Sales   int32
+	Pages   int32
+	Year    int32
+	Runtime data.Runtime
+	Genres  []string
+}
+
+// BookPatch mirrors BookInput but as pointers, so a nil field means "leave
+// this field unchanged" for a partial update.
+type BookPatch struct {
+	Title   *string
+	Sales   *int32
+	Pages   *int32
+	Year    *int32
+	Runtime *data.Runtime
+	Genres  []string
+}
+
+// BookListQuery holds the list endpoint's filter/sort/pagination inputs. Q
+// is the websearch_to_tsquery search string; when empty, List falls back to
+// the plain title/genres filtering it always did. Lang selects the search
+// text configuration ("simple", "english" or "russian").
+type BookListQuery struct {
+	Title    string
+	Sales    int32
+	Pages    int32
+	Genres   []string
+	Q        string
+	Lang     string
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+// Books is the core service for book records. Model is a data.BookStore so
+// the same core logic runs unchanged against either the Postgres or the
+// SQLite backend.
+type Books struct {
+	Model data.BookStore
+}
+
+// Create validates input and inserts a new book record.
+func (b Books) Create(ctx context.Context, input BookInput) (*data.Books, error) {
+	book := &data.Books{
+		Title:   input.Title,
+		Sales:   input.Sales,
+		Pages:   input.Pages,
+		Year:    input.Year,
+		Runtime: input.Runtime,
+		Genres:  input.Genres,
+	}
+
+	v := validator.New()
+	if data.ValidateBook(v, book); !v.Valid() {
+		return nil, &ValidationError{Errors: v.Errors}
+	}
+
+	if err := b.Model.Insert(book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Get fetches a single book, translating a missing record to ErrNotFound.
+func (b Books) Get(ctx context.Context, id int64) (*data.Books, error) {
+	book, err := b.Model.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return book, nil
+}
+
+// Update validates patch merged onto the current record, then applies it at
+// the store layer via PartialUpdate, so the actual write is guarded by the
+// database's own version check instead of a Get-then-Update window in which
+// a concurrent write could be silently clobbered by a write built from a
+// now-stale read. expectedVersion is ignored when matchAny is true (no
+// If-Match supplied); otherwise a version mismatch - checked again by
+// PartialUpdate's own WHERE clause at write time, not just here - is
+// reported as ErrConflict.
+func (b Books) Update(ctx context.Context, id int64, patch BookPatch, expectedVersion int32, matchAny bool) (*data.Books, error) {
+	book, err := b.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !matchAny && book.Version != expectedVersion {
+		return nil, ErrConflict
+	}
+
+	if patch.Title != nil {
+		book.Title = *patch.Title
+	}
+	if patch.Sales != nil {
+		book.Sales = *patch.Sales
+	}
+	if patch.Pages != nil {
+		book.Pages = *patch.Pages
+	}
+	if patch.Year != nil {
+		book.Year = *patch.Year
+	}
+	if patch.Runtime != nil {
+		book.Runtime = *patch.Runtime
+	}
+	if patch.Genres != nil {
+		book.Genres = patch.Genres
+	}
+
+	v := validator.New()
+	if data.ValidateBook(v, book); !v.Valid() {
+		return nil, &ValidationError{Errors: v.Errors}
+	}
+
+	updated, err := b.Model.PartialUpdate(ctx, id, data.BookPatch{
+		Title:   patch.Title,
+		Sales:   patch.Sales,
+		Pages:   patch.Pages,
+		Year:    patch.Year,
+		Runtime: patch.Runtime,
+		Genres:  patch.Genres,
+	}, book.Version, matchAny)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete removes a book. As with Update, the version check isn't done by
+// reading the record and comparing in Go (a concurrent write between that
+// read and the delete could silently bypass the precondition the client
+// asked for) - it's enforced atomically by the store's own
+// WHERE id = $n AND (version = $n OR matchAny) delete. The Get here exists
+// only to translate a missing id to ErrNotFound up front.
+func (b Books) Delete(ctx context.Context, id int64, expectedVersion int32, matchAny bool) error {
+	if _, err := b.Get(ctx, id); err != nil {
+		return err
+	}
+
+	if err := b.Model.Delete(id, expectedVersion, matchAny); err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return ErrConflict
+		}
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Import bulk-inserts rows, each already tagged with the line it came from
+// in the caller's source file, reusing the store's own per-row validation
+// and CopyFrom/batch-insert logic. This, rather than reaching into
+// data.BookStore directly, is cmd/api's path into the store for bulk
+// import, same as Create/Update/Delete/List are for the single-record and
+// listing handlers.
+func (b Books) Import(ctx context.Context, rows []data.ImportRow) ([]data.ImportResult, error) {
+	return b.Model.InsertBatch(ctx, rows)
+}
+
+// Export validates the sort filter, then streams every matching book
+// through fn as it comes off the store's cursor, so cmd/api's bulk-export
+// handler shares the same validation and store access as the rest of
+// Books instead of calling data.BookStore directly. Unlike List, it uses
+// ValidateSort rather than ValidateFilters: StreamAll has no Page/PageSize
+// concept (it streams the whole cursor), so there are no pagination bounds
+// for Export to enforce, and it shouldn't reject callers for zero-valued
+// fields it never reads.
+func (b Books) Export(ctx context.Context, title string, genres []string, filters data.Filters, fn func(*data.Books) error) error {
+	v := validator.New()
+	if data.ValidateSort(v, filters); !v.Valid() {
+		return &ValidationError{Errors: v.Errors}
+	}
+	return b.Model.StreamAll(ctx, title, genres, filters, fn)
+}
+
+// List applies the default sort safelist and pagination rules and returns a
+// page of books matching the query.
+func (b Books) List(ctx context.Context, q BookListQuery) ([]*data.Books, data.Metadata, error) {
+	filters := data.Filters{
+		Page:         q.Page,
+		PageSize:     q.PageSize,
+		Sort:         q.Sort,
+		SortSafelist: bookSortSafelist,
+	}
+
+	v := validator.New()
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, data.Metadata{}, &ValidationError{Errors: v.Errors}
+	}
+
+	books, metadata, err := b.Model.GetAll(q.Title, q.Sales, q.Pages, q.Genres, q.Q, q.Lang, filters)
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	return books, metadata, nil
+}
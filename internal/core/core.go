@@ -0,0 +1,44 @@
+// Package core holds the application's business logic: validation, the
+// database calls and the policy decisions (default sort order, safelists,
+// version-check semantics) around them. It depends only on internal/data
+// and internal/validator, not on net/http, so it can be called from the API
+// handlers, a CLI, a background job, or a test without an httptest server.
+package core
+
+import (
+	"errors"
+
+	"greenlight.zhaksylyk.kz/internal/data"
+)
+
+// Sentinel errors returned by core methods. The HTTP layer (cmd/api)
+// translates these into status codes; other callers can match on them
+// directly with errors.Is instead of parsing a status code.
+var (
+	ErrValidation = errors.New("validation failed")
+	ErrConflict   = errors.New("edit conflict")
+	ErrNotFound   = errors.New("record not found")
+)
+
+// ValidationError wraps ErrValidation with the field-level messages a
+// validator.Validator collected, so the HTTP layer can still render them
+// the way failedValidationResponse expects.
+type ValidationError struct {
+	Errors map[string]string
+}
+
+func (e *ValidationError) Error() string { return ErrValidation.Error() }
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+// Services bundles the core services the API handlers call into.
+type Services struct {
+	Books Books
+}
+
+// New builds the core Services on top of the given data models.
+func New(models data.Models) Services {
+	return Services{
+		Books: Books{Model: models.Books},
+	}
+}
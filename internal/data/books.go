@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"greenlight.zhaksylyk.kz/internal/validator"
+	"strings"
 	"time"
 )
 
@@ -20,6 +22,15 @@ type Books struct {
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
 	Version   int32     `json:"version"`
+	Highlight string    `json:"highlight,omitempty"`
+}
+
+// searchLangs are the text search configurations we accept for the `lang`
+// query parameter, matching the audiences this deployment actually serves.
+var searchLangs = map[string]bool{
+	"simple":  true,
+	"english": true,
+	"russian": true,
 }
 
 func ValidateBook(v *validator.Validator, book *Books) {
@@ -78,17 +89,22 @@ WHERE id = $1`
 	// method returns.
 	defer cancel()
 
-	err := m.DB.QueryRow(ctx, query, id).Scan(
-		&book.ID,
-		&book.CreatedAt,
-		&book.Title,
-		&book.Sales,
-		&book.Pages,
-		&book.Year,
-		&book.Runtime,
-		&book.Genres,
-		&book.Version,
-	)
+	// Run inside a read-only deferrable snapshot so that a show endpoint
+	// built on top of this later (e.g. fetching related rows alongside the
+	// book) sees one consistent view of the database, the same as GetAll.
+	err := WithReadOnlyTx(ctx, m.DB, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, query, id).Scan(
+			&book.ID,
+			&book.CreatedAt,
+			&book.Title,
+			&book.Sales,
+			&book.Pages,
+			&book.Year,
+			&book.Runtime,
+			&book.Genres,
+			&book.Version,
+		)
+	})
 	// Handle any errors. If there was no matching book found, Scan() will return
 	// a sql.ErrNoRows error. We check for this and return our custom ErrRecordNotFound
 	// error instead.
@@ -105,19 +121,20 @@ WHERE id = $1`
 }
 
 func (m BookModel) Update(book *Books) error {
-	// Add the 'AND version = $6' clause to the SQL query.
+	// Add the 'AND version = $8' clause to the SQL query so that the update
+	// only applies against the version the caller last read, and bump the
+	// version itself as part of the same statement.
 	query := `
-SELECT id, created_at, title, sales, pages, year, runtime, genres, version
-FROM books
-WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-AND (genres @> $2 OR $2 = '{}')
-ORDER BY id`
+UPDATE books
+SET title = $1, sales = $2, pages = $3, year = $4, runtime = $5, genres = $6, version = version + 1
+WHERE id = $7 AND version = $8
+RETURNING version`
 
 	args := []any{
 		book.Title,
-		book.Year,
 		book.Sales,
 		book.Pages,
+		book.Year,
 		book.Runtime,
 		book.Genres,
 		book.ID,
@@ -142,73 +159,232 @@ ORDER BY id`
 	return nil
 }
 
-func (m BookModel) Delete(id int64) error {
+// BookPatch mirrors Books but with pointer fields, so PartialUpdate can tell
+// "not supplied" apart from the zero value.
+type BookPatch struct {
+	Title   *string
+	Sales   *int32
+	Pages   *int32
+	Year    *int32
+	Runtime *Runtime
+	Genres  []string
+}
+
+// PartialUpdate applies only the non-nil fields in patch directly at the SQL
+// layer, building the SET clause dynamically. Unlike Update, which expects
+// the caller to have already fetched and mutated a Books record, this
+// updates by id alone, so there's no Get-then-Update window in which a
+// concurrent write can be silently clobbered by a write built from a
+// now-stale read. The write is still guarded by the same optimistic-
+// concurrency check as Update: unless matchAny is set, it's conditioned on
+// the row's current version matching expectedVersion, and a mismatch (or a
+// missing row) comes back as ErrEditConflict, same as Update.
+func (m BookModel) PartialUpdate(ctx context.Context, id int64, patch BookPatch, expectedVersion int32, matchAny bool) (*Books, error) {
+	setClauses := make([]string, 0, 6)
+	args := make([]any, 0, 9)
+
+	addSet := func(column string, value any) {
+		args = append(args, value)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.Title != nil {
+		addSet("title", *patch.Title)
+	}
+	if patch.Sales != nil {
+		addSet("sales", *patch.Sales)
+	}
+	if patch.Pages != nil {
+		addSet("pages", *patch.Pages)
+	}
+	if patch.Year != nil {
+		addSet("year", *patch.Year)
+	}
+	if patch.Runtime != nil {
+		addSet("runtime", *patch.Runtime)
+	}
+	if patch.Genres != nil {
+		addSet("genres", patch.Genres)
+	}
+
+	if len(setClauses) == 0 {
+		return m.Get(id)
+	}
+
+	setClauses = append(setClauses, "version = version + 1")
+
+	args = append(args, id)
+	idPlaceholder := len(args)
+	args = append(args, expectedVersion)
+	versionPlaceholder := len(args)
+	args = append(args, matchAny)
+	matchAnyPlaceholder := len(args)
+
+	query := fmt.Sprintf(`
+UPDATE books
+SET %s
+WHERE id = $%d AND (version = $%d OR $%d)
+RETURNING id, created_at, title, sales, pages, year, runtime, genres, version`,
+		strings.Join(setClauses, ", "), idPlaceholder, versionPlaceholder, matchAnyPlaceholder)
+
+	var book Books
+
+	err := m.DB.QueryRow(ctx, query, args...).Scan(
+		&book.ID,
+		&book.CreatedAt,
+		&book.Title,
+		&book.Sales,
+		&book.Pages,
+		&book.Year,
+		&book.Runtime,
+		&book.Genres,
+		&book.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrEditConflict
+		default:
+			return nil, err
+		}
+	}
+	return &book, nil
+}
+
+// Delete removes a book, guarded by the same atomic
+// WHERE id = $n AND (version = $n OR matchAny) check PartialUpdate uses, so
+// a concurrent update between the caller's read and this delete can't
+// silently bypass an If-Match precondition. A matching caller that finds no
+// rows affected has already had the record's existence confirmed by the
+// Get that precedes this call in core.Books.Delete, so the remaining
+// failure mode here is a version mismatch: ErrEditConflict.
+func (m BookModel) Delete(id int64, expectedVersion int32, matchAny bool) error {
 	// Return an ErrRecordNotFound error if the movie ID is less than 1.
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	// Construct the SQL query to delete the record.
 	query := `
 DELETE FROM books
-WHERE id = $1`
+WHERE id = $1 AND (version = $2 OR $3)`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	// Execute the SQL query using the Exec() method, passing in the id variable as
-	// the value for the placeholder parameter. The Exec() method returns a sql.Result
-	// object.
-	result, err := m.DB.Exec(ctx, query, id)
+	result, err := m.DB.Exec(ctx, query, id, expectedVersion, matchAny)
 	if err != nil {
 		return err
 	}
-	// Call the RowsAffected() method on the sql.Result object to get the number of rows
-	// affected by the query.
 	rowsAffected := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	// If no rows were affected, we know that the movies table didn't contain a record
-	// with the provided ID at the moment we tried to delete it. In that case we
-	// return an ErrRecordNotFound error.
 	if rowsAffected == 0 {
-		return ErrRecordNotFound
+		return ErrEditConflict
 	}
 	return nil
 }
 
-func (m BookModel) GetAll(title string, sales int32, pages int32, genres []string, filters Filters) ([]*Books, Metadata, error) {
-	// Construct the SQL query to retrieve all movie records.
+// ImportResult reports the outcome of importing a single row, so that a
+// partial failure part-way through a large file is still actionable by the
+// caller instead of aborting the whole import with no indication of which
+// rows actually made it in.
+type ImportResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportRow pairs a book to insert with the line it came from in the
+// caller's source file, so the ImportResults InsertBatch returns reference
+// the same line numbering the caller used when reporting its own row parse
+// errors, instead of recomputing a line number from the row's position in
+// this slice.
+type ImportRow struct {
+	Line int
+	Book *Books
+}
+
+// InsertBatch validates every row and, for the ones that pass, bulk-loads
+// them with pgx.CopyFrom inside a single transaction. CopyFrom is far faster
+// than issuing one INSERT per row for large imports, but it doesn't support
+// RETURNING, so unlike Insert the books here are not populated with their
+// generated ID/CreatedAt/Version afterwards.
+func (m BookModel) InsertBatch(ctx context.Context, rows []ImportRow) ([]ImportResult, error) {
+	results := make([]ImportResult, len(rows))
+
+	copyRows := make([][]any, 0, len(rows))
+	valid := make([]int, 0, len(rows))
+
+	for i, row := range rows {
+		v := validator.New()
+		if ValidateBook(v, row.Book); !v.Valid() {
+			errs := make([]string, 0, len(v.Errors))
+			for field, msg := range v.Errors {
+				errs = append(errs, fmt.Sprintf("%s: %s", field, msg))
+			}
+			results[i] = ImportResult{Line: row.Line, Status: "invalid", Error: strings.Join(errs, "; ")}
+			continue
+		}
+		book := row.Book
+		copyRows = append(copyRows, []any{book.Title, book.Sales, book.Pages, book.Year, book.Runtime, book.Genres})
+		valid = append(valid, i)
+	}
+
+	if len(copyRows) == 0 {
+		return results, nil
+	}
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"books"},
+		[]string{"title", "sales", "pages", "year", "runtime", "genres"},
+		pgx.CopyFromRows(copyRows),
+	)
+	if err != nil {
+		for _, i := range valid {
+			results[i] = ImportResult{Line: rows[i].Line, Status: "error", Error: err.Error()}
+		}
+		return results, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, i := range valid {
+		results[i] = ImportResult{Line: rows[i].Line, Status: "ok"}
+	}
+
+	return results, nil
+}
+
+// StreamAll runs the same title/genres-filtered query as GetAll but invokes
+// fn once per row as it arrives off the wire instead of buffering the whole
+// result set in memory first. It has no sales/pages parameters - the bulk
+// export handler that's its only caller doesn't expose those as export
+// filters - so unlike GetAll it never needs to apply them; this is
+// consistent with the SQLite backend's StreamAll, not a gap. pgx.Rows is
+// itself cursor-backed at the protocol level, so callers that don't
+// accumulate rows (e.g. streaming straight to an HTTP response) get bounded
+// memory use no matter how many rows match.
+func (m BookModel) StreamAll(ctx context.Context, title string, genres []string, filters Filters, fn func(*Books) error) error {
 	query := fmt.Sprintf(`
-SELECT count(*) OVER(), id, created_at, title, sales, pages, year, runtime, genres, version
+SELECT id, created_at, title, sales, pages, year, runtime, genres, version
 FROM books
 WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
 AND (genres @> $2 OR $2 = '{}')
-ORDER BY %s %s, id ASC
-LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+ORDER BY %s %s, id ASC`, filters.sortColumn(), filters.sortDirection())
 
-	// Create a context with a 3-second timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	args := []any{title, sales, pages, genres, filters.limit(), filters.offset()}
-	// Use QueryContext() to execute the query. This returns a sql.Rows resultset
-	// containing the result.
-	rows, err := m.DB.Query(ctx, query, args...)
+	rows, err := m.DB.Query(ctx, query, title, genres)
 	if err != nil {
-		return nil, Metadata{}, err
+		return err
 	}
-
 	defer rows.Close()
-	// Initialize an empty slice to hold the movie data.
-	books := []*Books{}
-	totalRecords := 0
-	// Use rows.Next to iterate through the rows in the resultset.
+
 	for rows.Next() {
-		// Initialize an empty Books struct to hold the data for an individual book.
 		var book Books
-		// Scan the values from the row into the Books struct. Again, note that we're
-		// using the pq.Array() adapter on the genres field here.
 		err := rows.Scan(
-			&totalRecords,
 			&book.ID,
 			&book.CreatedAt,
 			&book.Title,
@@ -220,14 +396,142 @@ LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 			&book.Version,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return err
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetAll returns a page of books matching title/genres, or, when q is
+// non-empty, a websearch_to_tsquery full-text search ranked by ts_rank_cd
+// with a ts_headline snippet per row. lang picks the text search
+// configuration the search actually runs under:
+//   - "simple" (the default) matches against the stored tsv column (see
+//     migrations/000001_add_books_tsv.up.sql), which is indexed with GIN and
+//     kept current by a trigger, so it's the fast path.
+//   - "english"/"russian" build the tsvector inline with
+//     to_tsvector($lang::regconfig, ...) instead of reading tsv, since tsv
+//     is always tokenized as 'simple' and re-tokenizing the query alone
+//     under a different config without rebuilding the document side would
+//     silently miss real matches. This has no index to hit, so it costs a
+//     sequential scan, but it's correct: Russian/Kazakh-context callers
+//     asking for lang=russian actually get Russian-aware stemming and stop
+//     words, not a 'simple' search with their lang param silently ignored.
+//
+// Clients that don't pass q keep getting exactly the plainto_tsquery-on-title
+// behavior they always have, which is unaffected by lang.
+func (m BookModel) GetAll(title string, sales int32, pages int32, genres []string, q string, lang string, filters Filters) ([]*Books, Metadata, error) {
+	if lang == "" || !searchLangs[lang] {
+		lang = "simple"
+	}
+
+	var query string
+	var args []any
+
+	if q != "" {
+		orderBy := fmt.Sprintf("%s %s, id ASC", filters.sortColumn(), filters.sortDirection())
+		if filters.Sort == "relevance" || filters.Sort == "-relevance" {
+			orderBy = "rank DESC, id ASC"
 		}
-		// Add the Books struct to the slice.
-		books = append(books, &book)
+		if lang == "simple" {
+			query = fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, title, sales, pages, year, runtime, genres, version,
+	ts_rank_cd(tsv, websearch_to_tsquery('simple', $1)) AS rank,
+	ts_headline('simple', title, websearch_to_tsquery('simple', $1)) AS highlight
+FROM books
+WHERE tsv @@ websearch_to_tsquery('simple', $1)
+AND (genres @> $2 OR $2 = '{}')
+AND (sales = $3 OR $3 = 0)
+AND (pages = $4 OR $4 = 0)
+ORDER BY %s
+LIMIT $5 OFFSET $6`, orderBy)
+			args = []any{q, genres, sales, pages, filters.limit(), filters.offset()}
+		} else {
+			query = fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, title, sales, pages, year, runtime, genres, version,
+	ts_rank_cd(to_tsvector($2::regconfig, coalesce(title, '') || ' ' || array_to_string(coalesce(genres, '{}'), ' ')), websearch_to_tsquery($2::regconfig, $1)) AS rank,
+	ts_headline($2::regconfig, title, websearch_to_tsquery($2::regconfig, $1)) AS highlight
+FROM books
+WHERE to_tsvector($2::regconfig, coalesce(title, '') || ' ' || array_to_string(coalesce(genres, '{}'), ' ')) @@ websearch_to_tsquery($2::regconfig, $1)
+AND (genres @> $3 OR $3 = '{}')
+AND (sales = $4 OR $4 = 0)
+AND (pages = $5 OR $5 = 0)
+ORDER BY %s
+LIMIT $6 OFFSET $7`, orderBy)
+			args = []any{q, lang, genres, sales, pages, filters.limit(), filters.offset()}
+		}
+	} else {
+		// Construct the SQL query to retrieve all movie records.
+		query = fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, title, sales, pages, year, runtime, genres, version,
+	0::real AS rank, '' AS highlight
+FROM books
+WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+AND (genres @> $2 OR $2 = '{}')
+AND (sales = $3 OR $3 = 0)
+AND (pages = $4 OR $4 = 0)
+ORDER BY %s %s, id ASC
+LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+		args = []any{title, genres, sales, pages, filters.limit(), filters.offset()}
 	}
-	// When the rows.Next() loop has finished, call rows.Err() to retrieve any error
-	// that was encountered during the iteration.
-	if err = rows.Err(); err != nil {
+
+	// Create a context with a 3-second timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Initialize an empty slice to hold the movie data.
+	books := []*Books{}
+	totalRecords := 0
+
+	// Run the count(*) OVER() and the row scan inside a single read-only
+	// deferrable snapshot. Without this, a book inserted or deleted between
+	// the two could make totalRecords (and the resulting pagination
+	// metadata) drift from the rows actually returned in this response.
+	err := WithReadOnlyTx(ctx, m.DB, func(tx pgx.Tx) error {
+		// Use Query() to execute the query. This returns a pgx.Rows resultset
+		// containing the result.
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Use rows.Next to iterate through the rows in the resultset.
+		for rows.Next() {
+			// Initialize an empty Books struct to hold the data for an individual book.
+			var book Books
+			var rank float32
+			// Scan the values from the row into the Books struct. Again, note that we're
+			// using the pq.Array() adapter on the genres field here.
+			err := rows.Scan(
+				&totalRecords,
+				&book.ID,
+				&book.CreatedAt,
+				&book.Title,
+				&book.Sales,
+				&book.Pages,
+				&book.Year,
+				&book.Runtime,
+				&book.Genres,
+				&book.Version,
+				&rank,
+				&book.Highlight,
+			)
+			if err != nil {
+				return err
+			}
+			// Add the Books struct to the slice.
+			books = append(books, &book)
+		}
+		// When the rows.Next() loop has finished, call rows.Err() to retrieve any error
+		// that was encountered during the iteration.
+		return rows.Err()
+	})
+	if err != nil {
 		return nil, Metadata{}, err
 	}
 
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestPool connects to the Postgres instance named by GREENLIGHT_TEST_DSN
+// and skips the test when it isn't set, since BookModel.DB is a concrete
+// *pgxpool.Pool (unlike the sqlite backend's *sql.DB, it can't be swapped
+// for an in-memory fake), so exercising the real Update/PartialUpdate SQL
+// needs an actual Postgres database to run against.
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("GREENLIGHT_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GREENLIGHT_TEST_DSN not set; skipping Postgres-backed BookModel tests")
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(context.Background(), "TRUNCATE books RESTART IDENTITY"); err != nil {
+		t.Fatalf("TRUNCATE books: %v", err)
+	}
+
+	return pool
+}
+
+func mustInsertBook(t *testing.T, m BookModel) *Books {
+	t.Helper()
+
+	book := &Books{
+		Title:   "Dune",
+		Sales:   100,
+		Pages:   500,
+		Year:    1965,
+		Runtime: 1,
+		Genres:  []string{"sci-fi"},
+	}
+	if err := m.Insert(book); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	return book
+}
+
+func TestUpdateAppliesOnMatchingVersion(t *testing.T) {
+	m := BookModel{DB: newTestPool(t)}
+	book := mustInsertBook(t, m)
+
+	book.Title = "Dune Messiah"
+	if err := m.Update(book); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if book.Version != 2 {
+		t.Errorf("Version = %d, want 2", book.Version)
+	}
+
+	fetched, err := m.Get(book.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if fetched.Title != "Dune Messiah" {
+		t.Errorf("Title = %q, want %q", fetched.Title, "Dune Messiah")
+	}
+}
+
+func TestUpdateRejectsStaleVersion(t *testing.T) {
+	m := BookModel{DB: newTestPool(t)}
+	book := mustInsertBook(t, m)
+
+	stale := *book
+	stale.Version = book.Version + 1
+	stale.Title = "Dune Messiah"
+	err := m.Update(&stale)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Update with stale version: got %v, want ErrEditConflict", err)
+	}
+
+	unchanged, err := m.Get(book.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if unchanged.Title != book.Title {
+		t.Fatalf("Title changed despite the version conflict: got %q, want %q", unchanged.Title, book.Title)
+	}
+}
+
+func TestPartialUpdateRejectsStaleVersion(t *testing.T) {
+	m := BookModel{DB: newTestPool(t)}
+	book := mustInsertBook(t, m)
+
+	title := "Dune Messiah"
+	_, err := m.PartialUpdate(context.Background(), book.ID, BookPatch{Title: &title}, book.Version+1, false)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("PartialUpdate with stale version: got %v, want ErrEditConflict", err)
+	}
+
+	unchanged, err := m.Get(book.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if unchanged.Title != book.Title {
+		t.Fatalf("Title changed despite the version conflict: got %q, want %q", unchanged.Title, book.Title)
+	}
+}
+
+func TestPartialUpdateAppliesOnMatchingVersion(t *testing.T) {
+	m := BookModel{DB: newTestPool(t)}
+	book := mustInsertBook(t, m)
+
+	title := "Dune Messiah"
+	updated, err := m.PartialUpdate(context.Background(), book.ID, BookPatch{Title: &title}, book.Version, false)
+	if err != nil {
+		t.Fatalf("PartialUpdate: %v", err)
+	}
+	if updated.Title != title {
+		t.Errorf("Title = %q, want %q", updated.Title, title)
+	}
+	if updated.Version != book.Version+1 {
+		t.Errorf("Version = %d, want %d", updated.Version, book.Version+1)
+	}
+	if updated.Sales != book.Sales {
+		t.Errorf("Sales = %d, want unchanged %d", updated.Sales, book.Sales)
+	}
+}
+
+func TestPartialUpdateMatchAnyIgnoresExpectedVersion(t *testing.T) {
+	m := BookModel{DB: newTestPool(t)}
+	book := mustInsertBook(t, m)
+
+	title := "Children of Dune"
+	updated, err := m.PartialUpdate(context.Background(), book.ID, BookPatch{Title: &title}, book.Version+99, true)
+	if err != nil {
+		t.Fatalf("PartialUpdate with matchAny: %v", err)
+	}
+	if updated.Title != title {
+		t.Errorf("Title = %q, want %q", updated.Title, title)
+	}
+}
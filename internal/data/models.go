@@ -11,12 +11,15 @@ var (
 )
 
 type Models struct {
-	Books       BookModel
+	Books       BookStore
 	Permissions PermissionModel
 	Tokens      TokenModel
 	Users       UserModel
 }
 
+// NewModels builds the Postgres-backed Models. Use this when cfg.db.dsn has
+// a "postgres://" (or "postgresql://") scheme; for "sqlite://" use
+// sqlite.Models (internal/data/sqlite) instead.
 func NewModels(db *pgxpool.Pool) Models {
 	return Models{
 		Books:       BookModel{DB: db},
@@ -0,0 +1,528 @@
+// Package sqlite provides a SQLite-backed implementation of data.BookStore,
+// selected when the configured DSN has a "sqlite://" scheme. It exists so
+// that `go test ./...` and local development work without a Postgres
+// instance; the Postgres-specific bits of the pgx implementation (ARRAY
+// genres, the @> containment operator, to_tsvector/ts_rank_cd search) are
+// reimplemented here using SQLite's equivalents (a JSON-encoded genres
+// column, Go-side containment filtering, and an FTS5 virtual table).
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"greenlight.zhaksylyk.kz/internal/data"
+	"greenlight.zhaksylyk.kz/internal/validator"
+)
+
+// Open opens (creating if necessary) a SQLite database at path and ensures
+// the books table, its FTS5 index and sync triggers exist.
+func Open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func ensureSchema(db *sql.DB) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS books (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			title TEXT NOT NULL,
+			sales INTEGER NOT NULL DEFAULT 0,
+			pages INTEGER NOT NULL DEFAULT 0,
+			year INTEGER NOT NULL DEFAULT 0,
+			runtime INTEGER NOT NULL DEFAULT 0,
+			genres TEXT NOT NULL DEFAULT '[]',
+			version INTEGER NOT NULL DEFAULT 1
+		)`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS books_fts USING fts5(
+			title, genres, content='books', content_rowid='id'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS books_ai AFTER INSERT ON books BEGIN
+			INSERT INTO books_fts(rowid, title, genres) VALUES (new.id, new.title, new.genres);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS books_ad AFTER DELETE ON books BEGIN
+			INSERT INTO books_fts(books_fts, rowid, title, genres) VALUES ('delete', old.id, old.title, old.genres);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS books_au AFTER UPDATE ON books BEGIN
+			INSERT INTO books_fts(books_fts, rowid, title, genres) VALUES ('delete', old.id, old.title, old.genres);
+			INSERT INTO books_fts(rowid, title, genres) VALUES (new.id, new.title, new.genres);
+		END`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("sqlite: %w", err)
+		}
+	}
+	return nil
+}
+
+// BookModel is the SQLite-backed implementation of data.BookStore.
+type BookModel struct {
+	DB *sql.DB
+}
+
+func encodeGenres(genres []string) (string, error) {
+	b, err := json.Marshal(genres)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func decodeGenres(s string) ([]string, error) {
+	var genres []string
+	if s == "" {
+		return genres, nil
+	}
+	if err := json.Unmarshal([]byte(s), &genres); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// sortColumnDirection turns a data.Filters.Sort value (e.g. "-year") into
+// the column/direction pair GetAll and StreamAll order by. "relevance" has
+// no meaning outside of an FTS5 MATCH query, so it falls back to "id" like
+// an empty sort would.
+func sortColumnDirection(sort string) (string, string) {
+	column := strings.TrimPrefix(sort, "-")
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+	}
+	if column == "" || column == "relevance" {
+		column = "id"
+	}
+	return column, direction
+}
+
+// limitOffset mirrors data.Filters.limit()/offset(), which aren't exported
+// for use outside the data package.
+func limitOffset(filters data.Filters) (limit, offset int) {
+	pageSize := filters.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	page := filters.Page
+	if page <= 0 {
+		page = 1
+	}
+	return pageSize, (page - 1) * pageSize
+}
+
+// buildMetadata mirrors data.calculateMetadata, which isn't exported for use
+// outside the data package.
+func buildMetadata(totalRecords, page, pageSize int) data.Metadata {
+	if totalRecords == 0 {
+		return data.Metadata{}
+	}
+	return data.Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
+}
+
+// containsAll reports whether have contains every genre in want, mirroring
+// Postgres's `genres @> want` array containment operator.
+func containsAll(have, want []string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	set := make(map[string]bool, len(have))
+	for _, g := range have {
+		set[g] = true
+	}
+	for _, g := range want {
+		if !set[g] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m BookModel) Insert(book *data.Books) error {
+	v := validator.New()
+	if data.ValidateBook(v, book); !v.Valid() {
+		return fmt.Errorf("invalid book: %v", v.Errors)
+	}
+
+	genresJSON, err := encodeGenres(book.Genres)
+	if err != nil {
+		return err
+	}
+
+	query := `
+INSERT INTO books (title, sales, pages, year, runtime, genres, created_at, version)
+VALUES (?, ?, ?, ?, ?, ?, ?, 1)`
+
+	book.CreatedAt = time.Now()
+	result, err := m.DB.Exec(query, book.Title, book.Sales, book.Pages, book.Year, book.Runtime, genresJSON, book.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	book.ID = id
+	book.Version = 1
+	return nil
+}
+
+func (m BookModel) Get(id int64) (*data.Books, error) {
+	if id < 1 {
+		return nil, data.ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, title, sales, pages, year, runtime, genres, version
+FROM books WHERE id = ?`
+
+	var book data.Books
+	var genresJSON string
+
+	err := m.DB.QueryRow(query, id).Scan(
+		&book.ID, &book.CreatedAt, &book.Title, &book.Sales, &book.Pages,
+		&book.Year, &book.Runtime, &genresJSON, &book.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, data.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	book.Genres, err = decodeGenres(genresJSON)
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (m BookModel) Update(book *data.Books) error {
+	genresJSON, err := encodeGenres(book.Genres)
+	if err != nil {
+		return err
+	}
+
+	query := `
+UPDATE books
+SET title = ?, sales = ?, pages = ?, year = ?, runtime = ?, genres = ?, version = version + 1
+WHERE id = ? AND version = ?`
+
+	result, err := m.DB.Exec(query, book.Title, book.Sales, book.Pages, book.Year, book.Runtime, genresJSON, book.ID, book.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return data.ErrEditConflict
+	}
+
+	book.Version++
+	return nil
+}
+
+// PartialUpdate mirrors the pgx BookModel's PartialUpdate, including its
+// optimistic-concurrency guard: unless matchAny is set, the write only
+// applies if the row's current version still matches expectedVersion, and a
+// mismatch (or a missing row) is reported as ErrEditConflict.
+func (m BookModel) PartialUpdate(ctx context.Context, id int64, patch data.BookPatch, expectedVersion int32, matchAny bool) (*data.Books, error) {
+	setClauses := make([]string, 0, 6)
+	args := make([]any, 0, 9)
+
+	if patch.Title != nil {
+		setClauses = append(setClauses, "title = ?")
+		args = append(args, *patch.Title)
+	}
+	if patch.Sales != nil {
+		setClauses = append(setClauses, "sales = ?")
+		args = append(args, *patch.Sales)
+	}
+	if patch.Pages != nil {
+		setClauses = append(setClauses, "pages = ?")
+		args = append(args, *patch.Pages)
+	}
+	if patch.Year != nil {
+		setClauses = append(setClauses, "year = ?")
+		args = append(args, *patch.Year)
+	}
+	if patch.Runtime != nil {
+		setClauses = append(setClauses, "runtime = ?")
+		args = append(args, *patch.Runtime)
+	}
+	if patch.Genres != nil {
+		genresJSON, err := encodeGenres(patch.Genres)
+		if err != nil {
+			return nil, err
+		}
+		setClauses = append(setClauses, "genres = ?")
+		args = append(args, genresJSON)
+	}
+
+	if len(setClauses) == 0 {
+		return m.Get(id)
+	}
+
+	setClauses = append(setClauses, "version = version + 1")
+
+	matchAnyInt := 0
+	if matchAny {
+		matchAnyInt = 1
+	}
+	args = append(args, id, expectedVersion, matchAnyInt)
+
+	query := fmt.Sprintf(`UPDATE books SET %s WHERE id = ? AND (version = ? OR ?)`, strings.Join(setClauses, ", "))
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, data.ErrEditConflict
+	}
+
+	return m.Get(id)
+}
+
+// Delete mirrors the pgx BookModel.Delete's atomic version guard: the
+// caller's existence check happens in core.Books.Delete's preceding Get,
+// so zero rows affected here means the version didn't match.
+func (m BookModel) Delete(id int64, expectedVersion int32, matchAny bool) error {
+	if id < 1 {
+		return data.ErrRecordNotFound
+	}
+
+	matchAnyInt := 0
+	if matchAny {
+		matchAnyInt = 1
+	}
+
+	result, err := m.DB.Exec(`DELETE FROM books WHERE id = ? AND (version = ? OR ?)`, id, expectedVersion, matchAnyInt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return data.ErrEditConflict
+	}
+	return nil
+}
+
+// GetAll mirrors the pgx BookModel.GetAll, but SQLite has no array
+// containment operator, so the genres filter is applied in Go after the SQL
+// query returns candidate rows; sales/pages are equality filters and could
+// be pushed into the SQL WHERE clause, but they're applied alongside genres
+// for the same reason - one candidate-filtering pass in Go instead of two
+// different filtering strategies. That's an acceptable tradeoff for the
+// local-dev/test-only dataset sizes this backend targets; a production
+// deployment should use the Postgres backend. When q is set, the FTS5 search
+// orders results by bm25 rank whatever filters.Sort says, and lang is not
+// used.
+func (m BookModel) GetAll(title string, sales int32, pages int32, genres []string, q string, lang string, filters data.Filters) ([]*data.Books, data.Metadata, error) {
+	var rows *sql.Rows
+	var err error
+
+	if q != "" {
+		query := `
+SELECT b.id, b.created_at, b.title, b.sales, b.pages, b.year, b.runtime, b.genres, b.version,
+	snippet(books_fts, 0, '<mark>', '</mark>', '...', 10) AS highlight
+FROM books_fts
+JOIN books b ON b.id = books_fts.rowid
+WHERE books_fts MATCH ?
+ORDER BY bm25(books_fts)`
+		rows, err = m.DB.Query(query, q)
+	} else {
+		column, direction := sortColumnDirection(filters.Sort)
+		query := fmt.Sprintf(`
+SELECT id, created_at, title, sales, pages, year, runtime, genres, version, ''
+FROM books
+WHERE (title LIKE '%%' || ? || '%%' OR ? = '')
+ORDER BY %s %s`, column, direction)
+		rows, err = m.DB.Query(query, title, title)
+	}
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	defer rows.Close()
+
+	var books []*data.Books
+	for rows.Next() {
+		var book data.Books
+		var genresJSON string
+		err := rows.Scan(
+			&book.ID, &book.CreatedAt, &book.Title, &book.Sales, &book.Pages,
+			&book.Year, &book.Runtime, &genresJSON, &book.Version, &book.Highlight,
+		)
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		book.Genres, err = decodeGenres(genresJSON)
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		if !containsAll(book.Genres, genres) {
+			continue
+		}
+		if sales != 0 && book.Sales != sales {
+			continue
+		}
+		if pages != 0 && book.Pages != pages {
+			continue
+		}
+		books = append(books, &book)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	totalRecords := len(books)
+	limit, offset := limitOffset(filters)
+	start := offset
+	if start > totalRecords {
+		start = totalRecords
+	}
+	end := start + limit
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	metadata := buildMetadata(totalRecords, filters.Page, filters.PageSize)
+	return books[start:end], metadata, nil
+}
+
+// InsertBatch inserts each valid row inside a single transaction. SQLite has
+// no COPY equivalent, so rows go in one at a time, but it's still one round
+// trip to the database file rather than one per row at the HTTP layer.
+func (m BookModel) InsertBatch(ctx context.Context, rows []data.ImportRow) ([]data.ImportResult, error) {
+	results := make([]data.ImportResult, len(rows))
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i, row := range rows {
+		book := row.Book
+		v := validator.New()
+		if data.ValidateBook(v, book); !v.Valid() {
+			errs := make([]string, 0, len(v.Errors))
+			for field, msg := range v.Errors {
+				errs = append(errs, fmt.Sprintf("%s: %s", field, msg))
+			}
+			results[i] = data.ImportResult{Line: row.Line, Status: "invalid", Error: strings.Join(errs, "; ")}
+			continue
+		}
+
+		genresJSON, err := encodeGenres(book.Genres)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+INSERT INTO books (title, sales, pages, year, runtime, genres, version)
+VALUES (?, ?, ?, ?, ?, ?, 1)`, book.Title, book.Sales, book.Pages, book.Year, book.Runtime, genresJSON)
+		if err != nil {
+			results[i] = data.ImportResult{Line: row.Line, Status: "error", Error: err.Error()}
+			continue
+		}
+		results[i] = data.ImportResult{Line: row.Line, Status: "ok"}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// StreamAll streams rows via database/sql's own cursor-backed *sql.Rows, the
+// same bounded-memory approach as the pgx implementation's StreamAll.
+func (m BookModel) StreamAll(ctx context.Context, title string, genres []string, filters data.Filters, fn func(*data.Books) error) error {
+	column, direction := sortColumnDirection(filters.Sort)
+	query := fmt.Sprintf(`
+SELECT id, created_at, title, sales, pages, year, runtime, genres, version
+FROM books
+WHERE (title LIKE '%%' || ? || '%%' OR ? = '')
+ORDER BY %s %s`, column, direction)
+
+	rows, err := m.DB.QueryContext(ctx, query, title, title)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book data.Books
+		var genresJSON string
+		err := rows.Scan(
+			&book.ID, &book.CreatedAt, &book.Title, &book.Sales, &book.Pages,
+			&book.Year, &book.Runtime, &genresJSON, &book.Version,
+		)
+		if err != nil {
+			return err
+		}
+		book.Genres, err = decodeGenres(genresJSON)
+		if err != nil {
+			return err
+		}
+		if !containsAll(book.Genres, genres) {
+			continue
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ErrAuthUnsupported is returned by Models alongside a still-usable Models
+// value to signal that this backend has no Permissions/Tokens/Users
+// implementation yet. It's informational, not necessarily fatal: a caller
+// that never registers auth routes (as cmd/api doesn't today) can log it
+// and keep running on the zero-valued fields. A caller that does wire up
+// auth endpoints against this backend should treat it as fatal instead,
+// since those zero-valued fields nil-pointer panic on first use.
+var ErrAuthUnsupported = errors.New("sqlite backend does not implement Permissions/Tokens/Users; auth endpoints are unavailable")
+
+// Models builds a data.Models backed by this SQLite database. Only Books is
+// wired up, so it also returns ErrAuthUnsupported - see its doc comment for
+// how callers should react.
+func Models(db *sql.DB) (data.Models, error) {
+	return data.Models{
+		Books: BookModel{DB: db},
+	}, ErrAuthUnsupported
+}
@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"greenlight.zhaksylyk.kz/internal/data"
+)
+
+func newTestModel(t *testing.T) BookModel {
+	t.Helper()
+
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return BookModel{DB: db}
+}
+
+func mustInsert(t *testing.T, m BookModel) *data.Books {
+	t.Helper()
+
+	book := &data.Books{
+		Title:   "Dune",
+		Sales:   100,
+		Pages:   500,
+		Year:    1965,
+		Runtime: 1,
+		Genres:  []string{"sci-fi"},
+	}
+	if err := m.Insert(book); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	return book
+}
+
+func TestPartialUpdateRejectsStaleVersion(t *testing.T) {
+	m := newTestModel(t)
+	book := mustInsert(t, m)
+
+	title := "Dune Messiah"
+	_, err := m.PartialUpdate(context.Background(), book.ID, data.BookPatch{Title: &title}, book.Version+1, false)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Fatalf("PartialUpdate with stale version: got %v, want ErrEditConflict", err)
+	}
+
+	unchanged, err := m.Get(book.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if unchanged.Title != book.Title {
+		t.Fatalf("Title changed despite the version conflict: got %q, want %q", unchanged.Title, book.Title)
+	}
+}
+
+func TestPartialUpdateAppliesOnMatchingVersion(t *testing.T) {
+	m := newTestModel(t)
+	book := mustInsert(t, m)
+
+	title := "Dune Messiah"
+	updated, err := m.PartialUpdate(context.Background(), book.ID, data.BookPatch{Title: &title}, book.Version, false)
+	if err != nil {
+		t.Fatalf("PartialUpdate: %v", err)
+	}
+	if updated.Title != title {
+		t.Errorf("Title = %q, want %q", updated.Title, title)
+	}
+	if updated.Version != book.Version+1 {
+		t.Errorf("Version = %d, want %d", updated.Version, book.Version+1)
+	}
+	// Fields not in the patch are left as they were.
+	if updated.Sales != book.Sales {
+		t.Errorf("Sales = %d, want unchanged %d", updated.Sales, book.Sales)
+	}
+}
+
+func TestPartialUpdateMatchAnyIgnoresExpectedVersion(t *testing.T) {
+	m := newTestModel(t)
+	book := mustInsert(t, m)
+
+	title := "Children of Dune"
+	updated, err := m.PartialUpdate(context.Background(), book.ID, data.BookPatch{Title: &title}, book.Version+99, true)
+	if err != nil {
+		t.Fatalf("PartialUpdate with matchAny: %v", err)
+	}
+	if updated.Title != title {
+		t.Errorf("Title = %q, want %q", updated.Title, title)
+	}
+}
+
+func TestGetAllFiltersOnSalesAndPages(t *testing.T) {
+	m := newTestModel(t)
+	dune := mustInsert(t, m)
+	other := &data.Books{
+		Title:   "Foundation",
+		Sales:   200,
+		Pages:   300,
+		Year:    1951,
+		Runtime: 1,
+		Genres:  []string{"sci-fi"},
+	}
+	if err := m.Insert(other); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "id"}
+
+	books, _, err := m.GetAll("", dune.Sales, 0, nil, "", "", filters)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(books) != 1 || books[0].ID != dune.ID {
+		t.Fatalf("GetAll(sales=%d) = %v, want only %q", dune.Sales, books, dune.Title)
+	}
+
+	books, _, err = m.GetAll("", 0, other.Pages, nil, "", "", filters)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(books) != 1 || books[0].ID != other.ID {
+		t.Fatalf("GetAll(pages=%d) = %v, want only %q", other.Pages, books, other.Title)
+	}
+}
@@ -0,0 +1,19 @@
+package data
+
+import "context"
+
+// BookStore is the set of operations any book storage backend must
+// support. BookModel (internal/data/books.go) is the Postgres-backed
+// default; internal/data/sqlite provides a SQLite-backed implementation of
+// the same interface for local development and tests that shouldn't need
+// Docker/Postgres.
+type BookStore interface {
+	Insert(books *Books) error
+	Get(id int64) (*Books, error)
+	Update(book *Books) error
+	PartialUpdate(ctx context.Context, id int64, patch BookPatch, expectedVersion int32, matchAny bool) (*Books, error)
+	Delete(id int64, expectedVersion int32, matchAny bool) error
+	GetAll(title string, sales int32, pages int32, genres []string, q string, lang string, filters Filters) ([]*Books, Metadata, error)
+	InsertBatch(ctx context.Context, rows []ImportRow) ([]ImportResult, error)
+	StreamAll(ctx context.Context, title string, genres []string, filters Filters, fn func(*Books) error) error
+}
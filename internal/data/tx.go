@@ -0,0 +1,37 @@
+package data
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WithReadOnlyTx runs fn inside a Postgres read-only, deferrable transaction,
+// committing on success and rolling back if fn returns an error. The
+// deferrable access mode asks Postgres to wait for a snapshot that's cheap to
+// use for a consistent read, which is what we want for multi-statement reads
+// like a paginated listing alongside its count(*) OVER() total: without it,
+// concurrent writes between the count and the row scan can make the reported
+// total drift from the rows actually returned.
+//
+// Only BookModel.Get and GetAll (internal/data/books.go) are wired through
+// this. The reads of PermissionModel, TokenModel and UserModel do not use
+// it and run outside any read-only snapshot.
+func WithReadOnlyTx(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{
+		AccessMode:     pgx.ReadOnly,
+		IsoLevel:       pgx.Serializable,
+		DeferrableMode: pgx.Deferrable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}